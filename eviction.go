@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// AnnotationTaintedTimestamp records when a node was first observed
+	// carrying the taint that is now past its ReadyDeadline.
+	AnnotationTaintedTimestamp = "node.vanstee.github.io/tainted-timestamp"
+	// AnnotationTaintedPreventEviction is a break-glass annotation operators
+	// can set on a pod to opt it out of eviction regardless of how long its
+	// node has been tainted.
+	AnnotationTaintedPreventEviction = "node.vanstee.github.io/tainted-prevent-eviction"
+	// AnnotationTaintedReason holds a human-readable explanation of why the
+	// pod was marked for eviction.
+	AnnotationTaintedReason = "node.vanstee.github.io/tainted-reason"
+
+	// EvictReasonReadyDeadlineExceeded labels evictions triggered because a
+	// rule's ReadyDeadline elapsed before its gating pods became ready.
+	EvictReasonReadyDeadlineExceeded = "ready_deadline_exceeded"
+
+	// EvictionGracePeriod is how long a pod is left alone after creation
+	// before it is eligible for eviction. Without this, a replacement pod
+	// created by the daemonset controller right after the previous one was
+	// evicted gets reconciled immediately, is (correctly) not yet ready, and
+	// sees the same already-elapsed ReadyDeadline — evicting it before it
+	// ever had a chance to start and looping forever. The grace period gives
+	// each new pod a real window to reach ready before it's judged stuck.
+	EvictionGracePeriod = 2 * time.Minute
+)
+
+var podsEvicted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "node_taint_manager_pods_evicted_total",
+	Help: "The number of pods node-taint-manager has evicted to unstick a node that failed to become ready within a rule's deadline.",
+}, []string{"reason"})
+
+// evictStuckPods annotates and evicts every non-ready pod this rule is
+// gating on, for a node that has carried the rule's taint past its
+// ReadyDeadline. PDBs are enforced by the API server's Eviction subresource,
+// pods younger than EvictionGracePeriod are skipped, and pods may opt out
+// via AnnotationTaintedPreventEviction.
+func (c *Controller) evictStuckPods(ctx context.Context, node *apiv1.Node, rule TaintRule, taintedSince time.Time, pods []interface{}) {
+	reason := fmt.Sprintf("node %s carried taint %s longer than its ready deadline of %s", node.ObjectMeta.Name, rule.TaintKey, rule.ReadyDeadline.Duration)
+
+	for _, ipod := range pods {
+		pod, ok := ipod.(*apiv1.Pod)
+		if !ok {
+			continue
+		}
+		if !rule.Matches(pod, c.ownerKind(pod), c.namespaceLabels(pod.ObjectMeta.Namespace)) {
+			continue
+		}
+		if rule.IsPodReady(pod) {
+			continue
+		}
+		if age := time.Since(pod.ObjectMeta.CreationTimestamp.Time); age < EvictionGracePeriod {
+			log.Printf("skipping eviction of %s/%s: pod is %s old, within the %s eviction grace period", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, age.Round(time.Second), EvictionGracePeriod)
+			continue
+		}
+		if pod.ObjectMeta.Annotations[AnnotationTaintedPreventEviction] == "true" {
+			log.Printf("skipping eviction of %s/%s: %s is set", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, AnnotationTaintedPreventEviction)
+			continue
+		}
+
+		if err := c.annotatePodTainted(ctx, pod, taintedSince, reason); err != nil {
+			log.Printf("failed to annotate pod %s/%s before eviction: %v", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, err)
+			continue
+		}
+
+		eviction := &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.ObjectMeta.Name,
+				Namespace: pod.ObjectMeta.Namespace,
+			},
+		}
+		if err := c.client.PolicyV1().Evictions(pod.ObjectMeta.Namespace).Evict(ctx, eviction); err != nil {
+			log.Printf("failed to evict pod %s/%s: %v", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, err)
+			c.recorder.Eventf(node, apiv1.EventTypeWarning, "EvictionFailed", "failed to evict stuck pod %s/%s: %v", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, err)
+			continue
+		}
+
+		log.Printf("evicted stuck pod %s/%s on node %s (rule %s)", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, node.ObjectMeta.Name, rule.Name)
+		podsEvicted.WithLabelValues(EvictReasonReadyDeadlineExceeded).Inc()
+		c.recorder.Eventf(pod, apiv1.EventTypeWarning, "Evicted", reason)
+		c.recorder.Eventf(node, apiv1.EventTypeWarning, "PodEvicted", "evicted stuck pod %s/%s: %s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, reason)
+	}
+}
+
+// annotatePodTainted records the tainted-timestamp and tainted-reason
+// annotations on pod before it is evicted, so operators can see why.
+func (c *Controller) annotatePodTainted(ctx context.Context, pod *apiv1.Pod, taintedSince time.Time, reason string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				AnnotationTaintedTimestamp: taintedSince.UTC().Format(time.RFC3339),
+				AnnotationTaintedReason:    reason,
+			},
+		},
+	}
+
+	bytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pod annotation patch: %w", err)
+	}
+
+	_, err = c.client.CoreV1().Pods(pod.ObjectMeta.Namespace).Patch(ctx, pod.ObjectMeta.Name, types.MergePatchType, bytes, metav1.PatchOptions{})
+	return err
+}
+
+// newEventRecorder builds a client-go event recorder that publishes Events
+// attributed to the node-taint-manager component.
+func newEventRecorder(broadcaster record.EventBroadcaster) record.EventRecorder {
+	return broadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: "node-taint-manager"})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// HealthStatus tracks the state exposed on /healthz and /readyz: whether the
+// informer caches have finished their initial sync, and whether this
+// replica currently holds the leader election lease.
+type HealthStatus struct {
+	mu       sync.RWMutex
+	synced   bool
+	isLeader bool
+}
+
+func (h *HealthStatus) SetSynced(synced bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.synced = synced
+}
+
+func (h *HealthStatus) SetLeader(isLeader bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.isLeader = isLeader
+}
+
+func (h *HealthStatus) snapshot() (synced, isLeader bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.synced, h.isLeader
+}
+
+// Healthz reports the process is alive and serving.
+func (h *HealthStatus) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz reports whether the informer caches have synced. Leader status is
+// included in the body for operators, but doesn't gate readiness: followers
+// are expected to be up and caches warm so they can take over quickly.
+func (h *HealthStatus) Readyz(w http.ResponseWriter, r *http.Request) {
+	synced, isLeader := h.snapshot()
+	if !synced {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "informer caches not yet synced")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "synced=%t leader=%t\n", synced, isLeader)
+}
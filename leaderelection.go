@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	DefaultLeaderElectionNamespace = "kube-system"
+	DefaultLeaderElectionName      = "node-taint-manager"
+
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// runWithLeaderElection blocks until ctx is done or this process loses the
+// namespace/name Lease, whichever comes first, running onStartedLeading for
+// as long as this process holds the Lease and stopping it the moment
+// leadership is lost. Once the Lease is lost it returns; it does not try to
+// reacquire it. Only one replica across the fleet is
+// ever leading at a time, so it's safe for onStartedLeading to drive the
+// reconciler even when multiple replicas are running.
+func runWithLeaderElection(ctx context.Context, client kubernetes.Interface, namespace, name string, health *HealthStatus, onStartedLeading func(context.Context)) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine hostname for leader election identity: %w", err)
+	}
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		namespace,
+		name,
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create leader election lock: %w", err)
+	}
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   DefaultLeaseDuration,
+		RenewDeadline:   DefaultRenewDeadline,
+		RetryPeriod:     DefaultRetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("acquired leader election lease %s/%s, starting reconciler", namespace, name)
+				health.SetLeader(true)
+				onStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				log.Printf("lost leader election lease %s/%s, stopping reconciler", namespace, name)
+				health.SetLeader(false)
+			},
+		},
+	})
+
+	return nil
+}
@@ -2,8 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -11,33 +10,46 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/avast/retry-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/homedir"
-	apiv1pod "k8s.io/kubernetes/pkg/api/v1/pod"
 )
 
 const (
-	DefaultResyncInterval         = 10 * time.Minute
-	DefaultReconciliationInterval = 5 * time.Second
+	DefaultResyncInterval = 10 * time.Minute
+
+	DefaultWorkerCount = 2
 
 	PodsInformerIndexByNodeName = "ByNodeName"
 
 	TaintNodeDaemonSetNotReady = "node.vanstee.github.io/daemonset-not-ready"
-
-	JSONPatchOperationOpRemove = "remove"
 )
 
+// DefaultTaintRules is used when no --rules config file is given, preserving
+// the original "wait for tolerating DaemonSet pods" behavior for the
+// well-known daemonset-not-ready taint.
+func DefaultTaintRules() []TaintRule {
+	rule := TaintRule{
+		Name:     "daemonset-not-ready",
+		TaintKey: TaintNodeDaemonSetNotReady,
+	}
+	if err := rule.init(); err != nil {
+		log.Fatalf("failed to initialize default taint rule: %v", err)
+	}
+	return []TaintRule{rule}
+}
+
 var (
 	totalNodeCount = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "node_taint_manager_nodes_monitored",
@@ -52,15 +64,28 @@ var (
 		Name:    "node_taint_manager_time_to_ready",
 		Help:    "Time in seconds taken for the all the daemonsets on the nodes to be ready",
 		Buckets: []float64{0.1, 1, 2, 3, 5, 10, 15, 20, 25, 30, 35, 40, 45, 50, 55, 60, 70, 80, 90, 100, 110, 120},
-	}, []string{})
+	}, []string{"taint_key"})
 )
 
-type JSONPatchOperation struct {
-	Op   string `json:"op"`
-	Path string `json:"path"`
-}
-
 func main() {
+	rulesPath := flag.String("rules", "", "path to a YAML/JSON file of TaintRules; if empty, falls back to the single built-in daemonset-not-ready rule")
+	workers := flag.Int("workers", DefaultWorkerCount, "number of reconcile worker goroutines to run")
+	leaderElect := flag.Bool("leader-elect", true, "run leader election so only one replica reconciles at a time")
+	leaderElectionNamespace := flag.String("leader-election-namespace", DefaultLeaderElectionNamespace, "namespace of the leader election Lease")
+	leaderElectionName := flag.String("leader-election-name", DefaultLeaderElectionName, "name of the leader election Lease")
+	flag.Parse()
+
+	var rules []TaintRule
+	if *rulesPath != "" {
+		loaded, err := LoadTaintRules(*rulesPath)
+		if err != nil {
+			log.Fatalf("failed to load taint rules: %v", err)
+		}
+		rules = loaded
+	} else {
+		rules = DefaultTaintRules()
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -92,6 +117,7 @@ func main() {
 				return &apiv1.Node{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:              t.ObjectMeta.Name,
+						UID:               t.ObjectMeta.UID,
 						CreationTimestamp: t.ObjectMeta.CreationTimestamp,
 					},
 					Spec: apiv1.NodeSpec{
@@ -101,9 +127,13 @@ func main() {
 			case *apiv1.Pod:
 				return &apiv1.Pod{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:            t.ObjectMeta.Name,
-						Namespace:       t.ObjectMeta.Namespace,
-						OwnerReferences: t.ObjectMeta.OwnerReferences,
+						Name:              t.ObjectMeta.Name,
+						Namespace:         t.ObjectMeta.Namespace,
+						UID:               t.ObjectMeta.UID,
+						Labels:            t.ObjectMeta.Labels,
+						Annotations:       t.ObjectMeta.Annotations,
+						OwnerReferences:   t.ObjectMeta.OwnerReferences,
+						CreationTimestamp: t.ObjectMeta.CreationTimestamp,
 					},
 					Spec: apiv1.PodSpec{
 						NodeName:    t.Spec.NodeName,
@@ -113,6 +143,21 @@ func main() {
 						Conditions: t.Status.Conditions,
 					},
 				}, nil
+			case *apiv1.Namespace:
+				return &apiv1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   t.ObjectMeta.Name,
+						Labels: t.ObjectMeta.Labels,
+					},
+				}, nil
+			case *appsv1.ReplicaSet:
+				return &appsv1.ReplicaSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            t.ObjectMeta.Name,
+						Namespace:       t.ObjectMeta.Namespace,
+						OwnerReferences: t.ObjectMeta.OwnerReferences,
+					},
+				}, nil
 			default:
 				return obj, nil
 			}
@@ -130,6 +175,10 @@ func main() {
 		},
 	})
 
+	namespacesInformer := factory.Core().V1().Namespaces().Informer()
+
+	replicaSetsInformer := factory.Apps().V1().ReplicaSets().Informer()
+
 	log.Printf("starting shared informer cache")
 	factory.Start(ctx.Done())
 	synced := factory.WaitForCacheSync(ctx.Done())
@@ -140,111 +189,60 @@ func main() {
 	}
 	log.Printf("shared informer cache fully synced")
 
-	reconciliationInterval := DefaultReconciliationInterval
-	ticker := time.NewTicker(reconciliationInterval)
-	log.Printf("reconciling node taints with daemonset pods every %d", reconciliationInterval)
+	health := &HealthStatus{}
+	health.SetSynced(true)
 
 	prometheus.Register(timeToStartup)
-	http.Handle("/metrics", promhttp.Handler())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", health.Healthz)
+	mux.HandleFunc("/readyz", health.Readyz)
+	metricsServer := &http.Server{Addr: ":9090", Handler: mux}
 	go func() {
-		log.Println("serving metrics on :9090/metrics")
-		if err := http.ListenAndServe(":9090", nil); err != http.ErrServerClosed {
+		log.Println("serving /metrics, /healthz and /readyz on :9090")
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("metrics server failed %v", err)
 		}
 	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to gracefully shut down metrics server: %v", err)
+		}
+	}()
 
-	// TODO: consider selecting a channel of events from informer, or use more
-	// custom watch implementation to speed things up (and save memory)
-	for {
-		select {
-		case <-ticker.C:
-			nodes := nodesInformer.GetIndexer().List()
-			totalNodeCount.Set(float64(len(nodes)))
-			for _, inode := range nodes {
-				node, ok := inode.(*apiv1.Node)
-				if !ok {
-					continue
-				}
-
-				taintIndex := -1
-				for i, taint := range node.Spec.Taints {
-					if taint.Key == TaintNodeDaemonSetNotReady {
-						taintIndex = i
-						break
-					}
-				}
-				if taintIndex == -1 {
-					continue
-				}
-
-				pods, err := podsInformer.GetIndexer().ByIndex(PodsInformerIndexByNodeName, node.ObjectMeta.Name)
-				if err != nil {
-					continue
-				}
-
-				// only proceed if all the tolerated daemonset pods on the node are ready
-				allPodsReady := true
-				for _, ipod := range pods {
-					pod, ok := ipod.(*apiv1.Pod)
-					if !ok {
-						continue
-					}
-					controller := metav1.GetControllerOfNoCopy(pod)
-					if controller == nil || controller.Kind != "DaemonSet" {
-						continue
-					}
-					toleratedPod := false
-					for _, toleration := range pod.Spec.Tolerations {
-						if toleration.Key == TaintNodeDaemonSetNotReady {
-							toleratedPod = true
-							break
-						}
-					}
-					if !toleratedPod {
-						continue
-					}
-					if !apiv1pod.IsPodReady(pod) {
-						allPodsReady = false
-						break
-					}
-				}
-				if !allPodsReady {
-					continue
-				}
-
-				// calculate the time here so potential slow node patching time doesn't get reflected in metrics
-				nodeTimeToReady := time.Since(time.Time(node.ObjectMeta.CreationTimestamp.Time)).Seconds()
-
-				patch := []JSONPatchOperation{
-					{
-						Op:   JSONPatchOperationOpRemove,
-						Path: fmt.Sprintf("/spec/taints/%d", taintIndex),
-					},
-				}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				totalNodeCount.Set(float64(len(nodesInformer.GetIndexer().List())))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-				bytes, err := json.Marshal(patch)
-				if err != nil {
-					continue
-				}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	defer broadcaster.Shutdown()
+	recorder := newEventRecorder(broadcaster)
 
-				err = retry.Do(
-					func() error {
-						_, err := client.CoreV1().Nodes().Patch(ctx, node.ObjectMeta.Name, types.JSONPatchType, bytes, metav1.PatchOptions{})
-						return err
-					},
-					retry.Attempts(3),
-				)
+	controller := NewController(client, rules, recorder, nodesInformer, podsInformer, namespacesInformer, replicaSetsInformer)
 
-				if err != nil {
-					continue
-				}
-				log.Printf("untainted node %s", node.ObjectMeta.Name)
+	if !*leaderElect {
+		log.Printf("reconciling node taints with %d workers (leader election disabled)", *workers)
+		controller.Run(ctx, *workers)
+		return
+	}
 
-				timeToStartup.WithLabelValues().Observe(nodeTimeToReady)
-				nodesUntainted.Inc()
-			}
-		case <-ctx.Done():
-			break
-		}
+	log.Printf("reconciling node taints with %d workers once leader election is won", *workers)
+	if err := runWithLeaderElection(ctx, client, *leaderElectionNamespace, *leaderElectionName, health, func(leaderCtx context.Context) {
+		controller.Run(leaderCtx, *workers)
+	}); err != nil {
+		log.Fatalf("leader election failed: %v", err)
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/avast/retry-go"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	JSONPatchOperationOpTest   = "test"
+	JSONPatchOperationOpRemove = "remove"
+)
+
+// JSONPatchOperation is a single RFC 6902 JSON patch operation.
+type JSONPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// buildTaintRemovalPatch returns a JSON patch that removes the taint
+// matching key from taints, guarded by a `test` op asserting the taint at
+// the computed index still has the expected key. Without the guard, an
+// index computed from a cached snapshot can point at a different taint by
+// the time the patch reaches the API server if another controller
+// concurrently added or removed a taint on the same node; the `test` op
+// turns that race into a patch failure instead of silently removing the
+// wrong taint. found is false if taints contains no taint with key.
+func buildTaintRemovalPatch(taints []apiv1.Taint, key string) (patch []byte, found bool, err error) {
+	index := -1
+	for i, taint := range taints {
+		if taint.Key == key {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, false, nil
+	}
+
+	ops := []JSONPatchOperation{
+		{
+			Op:    JSONPatchOperationOpTest,
+			Path:  fmt.Sprintf("/spec/taints/%d/key", index),
+			Value: key,
+		},
+		{
+			Op:   JSONPatchOperationOpRemove,
+			Path: fmt.Sprintf("/spec/taints/%d", index),
+		},
+	}
+
+	bytes, err := json.Marshal(ops)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal taint removal patch: %w", err)
+	}
+	return bytes, true, nil
+}
+
+// removeTaint removes taintKey from nodeName using a test-then-remove JSON
+// patch. If the patch fails its precondition because the taint array
+// shifted since nodesInformer's cache was last updated, it re-reads the
+// node from the informer and retries with a freshly computed index rather
+// than blindly retrying the same stale patch.
+func (c *Controller) removeTaint(ctx context.Context, nodeName, taintKey string) error {
+	return retry.Do(
+		func() error {
+			obj, exists, err := c.nodesInformer.GetIndexer().GetByKey(nodeName)
+			if err != nil {
+				return retry.Unrecoverable(fmt.Errorf("failed to look up node %s: %w", nodeName, err))
+			}
+			if !exists {
+				return nil
+			}
+			node, ok := obj.(*apiv1.Node)
+			if !ok {
+				return retry.Unrecoverable(fmt.Errorf("unexpected informer object type for node %s", nodeName))
+			}
+
+			patch, found, err := buildTaintRemovalPatch(node.Spec.Taints, taintKey)
+			if err != nil {
+				return retry.Unrecoverable(err)
+			}
+			if !found {
+				// already removed, nothing to do
+				return nil
+			}
+
+			_, err = c.client.CoreV1().Nodes().Patch(ctx, nodeName, types.JSONPatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+		retry.Attempts(3),
+		// return the last API error itself rather than retry-go's aggregate,
+		// so callers can classify it with the apierrors helpers
+		retry.LastErrorOnly(true),
+	)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestBuildTaintRemovalPatch(t *testing.T) {
+	taints := []apiv1.Taint{
+		{Key: TaintNodeDaemonSetNotReady, Effect: apiv1.TaintEffectNoSchedule},
+		{Key: "other.example.com/taint", Effect: apiv1.TaintEffectNoSchedule},
+	}
+
+	patch, found, err := buildTaintRemovalPatch(taints, TaintNodeDaemonSetNotReady)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found {
+		t.Fatalf("expected taint to be found")
+	}
+
+	result := applyPatch(t, patch, taints)
+	if len(result) != 1 || result[0].Key != "other.example.com/taint" {
+		t.Fatalf("expected only the unrelated taint to remain, got %+v", result)
+	}
+}
+
+func TestBuildTaintRemovalPatchNotFound(t *testing.T) {
+	_, found, err := buildTaintRemovalPatch(nil, TaintNodeDaemonSetNotReady)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found {
+		t.Fatalf("expected taint not to be found")
+	}
+}
+
+// TestBuildTaintRemovalPatchRejectsConcurrentMutation simulates another
+// controller inserting an unrelated taint between when this controller
+// computed the patch's expected index and when the patch is applied, and
+// asserts the `test` op makes the patch fail rather than silently removing
+// whatever taint ended up at that index.
+func TestBuildTaintRemovalPatchRejectsConcurrentMutation(t *testing.T) {
+	original := []apiv1.Taint{
+		{Key: TaintNodeDaemonSetNotReady, Effect: apiv1.TaintEffectNoSchedule},
+		{Key: "other.example.com/taint", Effect: apiv1.TaintEffectNoSchedule},
+	}
+
+	patch, found, err := buildTaintRemovalPatch(original, TaintNodeDaemonSetNotReady)
+	if err != nil || !found {
+		t.Fatalf("failed to build patch: found=%v err=%v", found, err)
+	}
+
+	// a concurrent controller prepends an unrelated taint, shifting our
+	// target taint from index 0 to index 1
+	mutated := append([]apiv1.Taint{
+		{Key: "concurrent.example.com/taint", Effect: apiv1.TaintEffectNoSchedule},
+	}, original...)
+
+	node := &apiv1.Node{Spec: apiv1.NodeSpec{Taints: mutated}}
+	nodeJSON, err := json.Marshal(node)
+	if err != nil {
+		t.Fatalf("failed to marshal node: %v", err)
+	}
+
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		t.Fatalf("failed to decode patch: %v", err)
+	}
+
+	if _, err := decoded.Apply(nodeJSON); err == nil {
+		t.Fatalf("expected patch to fail its test op against the mutated taint array, but it was applied")
+	}
+}
+
+func applyPatch(t *testing.T, patch []byte, taints []apiv1.Taint) []apiv1.Taint {
+	t.Helper()
+
+	node := &apiv1.Node{Spec: apiv1.NodeSpec{Taints: taints}}
+	nodeJSON, err := json.Marshal(node)
+	if err != nil {
+		t.Fatalf("failed to marshal node: %v", err)
+	}
+
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		t.Fatalf("failed to decode patch: %v", err)
+	}
+
+	patched, err := decoded.Apply(nodeJSON)
+	if err != nil {
+		t.Fatalf("failed to apply patch: %v", err)
+	}
+
+	var result apiv1.Node
+	if err := json.Unmarshal(patched, &result); err != nil {
+		t.Fatalf("failed to unmarshal patched node: %v", err)
+	}
+	return result.Spec.Taints
+}
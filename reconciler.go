@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	apiv1pod "k8s.io/kubernetes/pkg/api/v1/pod"
+)
+
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "node_taint_manager_queue_depth",
+		Help: "The current number of nodes queued for reconciliation.",
+	})
+
+	reconcileLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "node_taint_manager_reconcile_duration_seconds",
+		Help:    "Time in seconds taken to reconcile a single node.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	taintDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "node_taint_manager_taint_duration_seconds",
+		Help:    "Time in seconds a node carried a taint, from first observed to removal.",
+		Buckets: []float64{0.1, 1, 2, 3, 5, 10, 15, 20, 25, 30, 35, 40, 45, 50, 55, 60, 70, 80, 90, 100, 110, 120, 180, 240, 300, 600},
+	}, []string{"taint_key"})
+
+	daemonsetReadyDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "node_taint_manager_daemonset_ready_duration_seconds",
+		Help:    "Time in seconds from pod creation to PodReady, for daemonset pods node-taint-manager is gating on.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"namespace", "daemonset"})
+
+	patchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "node_taint_manager_patch_errors_total",
+		Help: "The number of errors encountered patching a node's taints, by reason.",
+	}, []string{"reason"})
+)
+
+// Controller watches nodes and pods and removes taints once their TaintRules
+// are satisfied. Work is enqueued by informer event handlers rather than
+// discovered by periodically scanning every node, so the time from "pod
+// ready" to "taint removed" is bounded by queue processing latency instead
+// of a fixed polling interval.
+type Controller struct {
+	client   kubernetes.Interface
+	rules    []TaintRule
+	recorder record.EventRecorder
+
+	nodesInformer       cache.SharedIndexInformer
+	podsInformer        cache.SharedIndexInformer
+	namespacesInformer  cache.SharedIndexInformer
+	replicaSetsInformer cache.SharedIndexInformer
+
+	queue workqueue.RateLimitingInterface
+
+	taintFirstSeenMu sync.Mutex
+	taintFirstSeen   map[string]time.Time
+
+	readyObservedMu sync.Mutex
+	readyObserved   map[types.UID]struct{}
+}
+
+// NewController wires up event handlers on the given informers that enqueue
+// the affected node name whenever a node is added or updated, a pod is
+// added, or a pod's PodReady condition flips. A change to another condition
+// a rule may gate on (ContainersReady or a custom condition) does not
+// enqueue the node unless PodReady changes with it. The informers are expected to already be registered with their
+// shared factory; NewController does not start them.
+func NewController(client kubernetes.Interface, rules []TaintRule, recorder record.EventRecorder, nodesInformer, podsInformer, namespacesInformer, replicaSetsInformer cache.SharedIndexInformer) *Controller {
+	c := &Controller{
+		client:              client,
+		rules:               rules,
+		recorder:            recorder,
+		nodesInformer:       nodesInformer,
+		podsInformer:        podsInformer,
+		namespacesInformer:  namespacesInformer,
+		replicaSetsInformer: replicaSetsInformer,
+		queue:               workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		taintFirstSeen:      make(map[string]time.Time),
+		readyObserved:       make(map[types.UID]struct{}),
+	}
+
+	nodesInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueNode,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueueNode(newObj) },
+	})
+
+	podsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePodNode,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*apiv1.Pod)
+			if !ok {
+				return
+			}
+			newPod, ok := newObj.(*apiv1.Pod)
+			if !ok {
+				return
+			}
+			if apiv1pod.IsPodReady(oldPod) == apiv1pod.IsPodReady(newPod) {
+				return
+			}
+			c.enqueuePodNode(newObj)
+		},
+		DeleteFunc: c.forgetPodObserved,
+	})
+
+	return c
+}
+
+func (c *Controller) enqueueNode(obj interface{}) {
+	node, ok := obj.(*apiv1.Node)
+	if !ok {
+		return
+	}
+	c.queue.Add(node.ObjectMeta.Name)
+}
+
+func (c *Controller) enqueuePodNode(obj interface{}) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return
+	}
+	c.queue.Add(pod.Spec.NodeName)
+}
+
+// forgetPodObserved clears the ready-observed bookkeeping for a deleted pod
+// so the map doesn't grow without bound.
+func (c *Controller) forgetPodObserved(obj interface{}) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			pod, ok = tombstone.Obj.(*apiv1.Pod)
+			if !ok {
+				return
+			}
+		} else {
+			return
+		}
+	}
+
+	c.readyObservedMu.Lock()
+	defer c.readyObservedMu.Unlock()
+	delete(c.readyObserved, pod.ObjectMeta.UID)
+}
+
+// Run starts numWorkers worker goroutines and blocks until ctx is done, at
+// which point it shuts down the workqueue. It returns without waiting for
+// queued or in-flight items to finish.
+func (c *Controller) Run(ctx context.Context, numWorkers int) {
+	defer c.queue.ShutDown()
+
+	for i := 0; i < numWorkers; i++ {
+		go c.worker(ctx)
+	}
+
+	go c.reportQueueDepth(ctx)
+
+	<-ctx.Done()
+}
+
+func (c *Controller) reportQueueDepth(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			queueDepth.Set(float64(c.queue.Len()))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Controller) worker(ctx context.Context) {
+	defer k8sruntime.HandleCrash()
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	nodeName, ok := key.(string)
+	if !ok {
+		c.queue.Forget(key)
+		return true
+	}
+
+	if err := c.reconcileNode(ctx, nodeName); err != nil {
+		log.Printf("failed to reconcile node %s, requeuing: %v", nodeName, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// taintFirstSeenKey keys the first-seen map by node UID rather than node
+// name so that a node deleted and recreated with the same name (e.g. during
+// a replace-type upgrade) doesn't inherit a stale taint age.
+func taintFirstSeenKey(nodeUID types.UID, taintKey string) string {
+	return string(nodeUID) + "/" + taintKey
+}
+
+// observeTaint records (and returns) the first time this node+taint pairing
+// was seen still present. Callers use the returned time to measure how long
+// a node has carried a taint.
+//
+// taint.TimeAdded, when set, is authoritative and is returned directly: it
+// lives on the Node object itself, so unlike the in-memory fallback below it
+// survives process restarts and leader-election failover. Only taints added
+// without a TimeAdded fall back to the in-memory first-seen map.
+func (c *Controller) observeTaint(nodeUID types.UID, taint apiv1.Taint) time.Time {
+	if taint.TimeAdded != nil {
+		return taint.TimeAdded.Time
+	}
+
+	key := taintFirstSeenKey(nodeUID, taint.Key)
+
+	c.taintFirstSeenMu.Lock()
+	defer c.taintFirstSeenMu.Unlock()
+
+	if firstSeen, ok := c.taintFirstSeen[key]; ok {
+		return firstSeen
+	}
+	now := time.Now()
+	c.taintFirstSeen[key] = now
+	return now
+}
+
+// forgetTaint clears the first-seen bookkeeping for a node+taint pairing
+// once the taint has been removed.
+func (c *Controller) forgetTaint(nodeUID types.UID, taintKey string) {
+	key := taintFirstSeenKey(nodeUID, taintKey)
+
+	c.taintFirstSeenMu.Lock()
+	defer c.taintFirstSeenMu.Unlock()
+
+	delete(c.taintFirstSeen, key)
+}
+
+// observeDaemonSetPodReady records how long a daemonset pod this controller
+// is gating on took to go from creation to PodReady, the first (and only
+// the first) time it's seen ready.
+func (c *Controller) observeDaemonSetPodReady(pod *apiv1.Pod) {
+	c.readyObservedMu.Lock()
+	if _, seen := c.readyObserved[pod.ObjectMeta.UID]; seen {
+		c.readyObservedMu.Unlock()
+		return
+	}
+	c.readyObserved[pod.ObjectMeta.UID] = struct{}{}
+	c.readyObservedMu.Unlock()
+
+	controller := metav1.GetControllerOfNoCopy(pod)
+	if controller == nil || controller.Kind != string(OwnerKindDaemonSet) {
+		return
+	}
+
+	readyDuration := time.Since(pod.ObjectMeta.CreationTimestamp.Time).Seconds()
+	daemonsetReadyDuration.WithLabelValues(pod.ObjectMeta.Namespace, controller.Name).Observe(readyDuration)
+}
+
+// classifyPatchError maps a node patch error to a low-cardinality reason
+// label for the node_taint_manager_patch_errors_total counter.
+func classifyPatchError(err error) string {
+	switch {
+	case apierrors.IsConflict(err):
+		return "conflict"
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	case apierrors.IsNotFound(err):
+		return "not_found"
+	default:
+		return "other"
+	}
+}
+
+// ownerKind resolves the controller kind that effectively owns pod, for
+// TaintRule.OwnerKinds matching purposes. Deployment-managed pods are
+// controlled by their ReplicaSet rather than the Deployment directly, so a
+// ReplicaSet-controlled pod's ReplicaSet is looked up to find its own
+// controller. Falls back to the pod's direct controller kind (including
+// "ReplicaSet" itself) if no owner reference is present or the ReplicaSet
+// can't be resolved from the informer cache.
+func (c *Controller) ownerKind(pod *apiv1.Pod) string {
+	return ownerKindForPod(pod, c.replicaSetsInformer.GetIndexer())
+}
+
+func ownerKindForPod(pod *apiv1.Pod, replicaSets cache.Indexer) string {
+	controller := metav1.GetControllerOfNoCopy(pod)
+	if controller == nil {
+		return ""
+	}
+	if controller.Kind != "ReplicaSet" {
+		return controller.Kind
+	}
+
+	obj, exists, err := replicaSets.GetByKey(pod.ObjectMeta.Namespace + "/" + controller.Name)
+	if err != nil || !exists {
+		return controller.Kind
+	}
+	rs, ok := obj.(*appsv1.ReplicaSet)
+	if !ok {
+		return controller.Kind
+	}
+	rsController := metav1.GetControllerOfNoCopy(rs)
+	if rsController == nil {
+		return controller.Kind
+	}
+	return rsController.Kind
+}
+
+func (c *Controller) namespaceLabels(namespace string) map[string]string {
+	obj, exists, err := c.namespacesInformer.GetIndexer().GetByKey(namespace)
+	if err != nil || !exists {
+		return nil
+	}
+	ns, ok := obj.(*apiv1.Namespace)
+	if !ok {
+		return nil
+	}
+	return ns.ObjectMeta.Labels
+}
+
+// reconcileNode evaluates every TaintRule against a single node and removes
+// any taint whose gating pods are all ready.
+func (c *Controller) reconcileNode(ctx context.Context, nodeName string) error {
+	start := time.Now()
+	defer func() { reconcileLatency.Observe(time.Since(start).Seconds()) }()
+
+	obj, exists, err := c.nodesInformer.GetIndexer().GetByKey(nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to look up node %s: %w", nodeName, err)
+	}
+	if !exists {
+		return nil
+	}
+	node, ok := obj.(*apiv1.Node)
+	if !ok {
+		return nil
+	}
+
+	for _, rule := range c.rules {
+		taintIndex := -1
+		for i, taint := range node.Spec.Taints {
+			if rule.matchesTaint(taint) {
+				taintIndex = i
+				break
+			}
+		}
+		if taintIndex == -1 {
+			c.forgetTaint(node.ObjectMeta.UID, rule.TaintKey)
+			continue
+		}
+		taintedSince := c.observeTaint(node.ObjectMeta.UID, node.Spec.Taints[taintIndex])
+
+		pods, err := c.podsInformer.GetIndexer().ByIndex(PodsInformerIndexByNodeName, node.ObjectMeta.Name)
+		if err != nil {
+			log.Printf("failed to list pods for node %s: %v", node.ObjectMeta.Name, err)
+			continue
+		}
+
+		// only proceed if every pod this rule is gating on is ready. This
+		// must not stop at the first non-ready pod: every other matching pod
+		// still needs to be checked (and, if ready, observed) on this pass,
+		// since informer iteration order is unspecified and a pod's ready
+		// observation should not wait on a sibling that happens to come
+		// later in the list.
+		allPodsReady := true
+		for _, ipod := range pods {
+			pod, ok := ipod.(*apiv1.Pod)
+			if !ok {
+				continue
+			}
+			if !rule.Matches(pod, c.ownerKind(pod), c.namespaceLabels(pod.ObjectMeta.Namespace)) {
+				continue
+			}
+			if !rule.IsPodReady(pod) {
+				allPodsReady = false
+				continue
+			}
+			c.observeDaemonSetPodReady(pod)
+		}
+		if !allPodsReady {
+			if rule.ReadyDeadline.Duration > 0 && time.Since(taintedSince) > rule.ReadyDeadline.Duration {
+				c.evictStuckPods(ctx, node, rule, taintedSince, pods)
+			}
+			continue
+		}
+
+		// calculate the time here so potential slow node patching time doesn't get reflected in metrics
+		nodeTimeToReady := time.Since(time.Time(node.ObjectMeta.CreationTimestamp.Time)).Seconds()
+
+		if err := c.removeTaint(ctx, node.ObjectMeta.Name, rule.TaintKey); err != nil {
+			log.Printf("failed to untaint node %s (rule %s): %v", node.ObjectMeta.Name, rule.Name, err)
+			patchErrorsTotal.WithLabelValues(classifyPatchError(err)).Inc()
+			continue
+		}
+		log.Printf("untainted node %s (rule %s)", node.ObjectMeta.Name, rule.Name)
+		c.forgetTaint(node.ObjectMeta.UID, rule.TaintKey)
+
+		timeToStartup.WithLabelValues(rule.TaintKey).Observe(nodeTimeToReady)
+		taintDuration.WithLabelValues(rule.TaintKey).Observe(time.Since(taintedSince).Seconds())
+		nodesUntainted.Inc()
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func podWithController(namespace, name, kind string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: kind, Name: name, Controller: boolPtr(true)},
+			},
+		},
+	}
+}
+
+func TestOwnerKindForPod(t *testing.T) {
+	replicaSets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	replicaSets.Add(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web-6d7f8c9b",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Deployment", Name: "web", Controller: boolPtr(true)},
+			},
+		},
+	})
+	replicaSets.Add(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "standalone-rs",
+		},
+	})
+
+	tests := []struct {
+		name string
+		pod  *apiv1.Pod
+		want string
+	}{
+		{"daemonset-controlled pod resolves directly", podWithController("default", "fluentd", "DaemonSet"), "DaemonSet"},
+		{"deployment-managed pod resolves through its replicaset", podWithController("default", "web-6d7f8c9b", "ReplicaSet"), "Deployment"},
+		{"replicaset without a deployment owner resolves to ReplicaSet", podWithController("default", "standalone-rs", "ReplicaSet"), "ReplicaSet"},
+		{"replicaset missing from the informer cache falls back to ReplicaSet", podWithController("default", "unknown-rs", "ReplicaSet"), "ReplicaSet"},
+		{"pod with no controller reference resolves to empty", &apiv1.Pod{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownerKindForPod(tt.pod, replicaSets); got != tt.want {
+				t.Errorf("ownerKindForPod() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
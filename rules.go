@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// OwnerKind identifies the controller kind that must own a pod for it to be
+// considered by a TaintRule.
+type OwnerKind string
+
+const (
+	OwnerKindDaemonSet   OwnerKind = "DaemonSet"
+	OwnerKindDeployment  OwnerKind = "Deployment"
+	OwnerKindStatefulSet OwnerKind = "StatefulSet"
+)
+
+// ReadinessPredicate selects how a TaintRule decides whether a gating pod is
+// ready to have its node's taint lifted.
+type ReadinessPredicate string
+
+const (
+	// ReadinessPredicatePodReady requires the standard PodReady condition.
+	ReadinessPredicatePodReady ReadinessPredicate = "PodReady"
+	// ReadinessPredicateContainersReady requires every container in the pod
+	// to report ready, ignoring readiness gates.
+	ReadinessPredicateContainersReady ReadinessPredicate = "ContainersReady"
+	// ReadinessPredicateCustomCondition requires CustomConditionType to be
+	// present on the pod with status True.
+	ReadinessPredicateCustomCondition ReadinessPredicate = "CustomCondition"
+)
+
+// TaintRule describes one independent bootstrap gate: a taint that should be
+// removed from a node once every pod matching PodSelector (and tolerating
+// TaintKey) satisfies Readiness. Rules are evaluated independently of one
+// another, so multiple teams can register gates on the same node without
+// stepping on each other.
+type TaintRule struct {
+	// Name identifies the rule in logs and metrics.
+	Name string `json:"name"`
+	// TaintKey is the taint this rule manages. A node is only considered for
+	// this rule if it carries a taint with this key.
+	TaintKey string `json:"taintKey"`
+	// TaintEffect restricts matching to taints with this effect. Empty
+	// matches any effect.
+	TaintEffect apiv1.TaintEffect `json:"taintEffect,omitempty"`
+	// OwnerKinds restricts matching pods to those owned by one of these
+	// controller kinds. Defaults to DaemonSet for backwards compatibility.
+	OwnerKinds []OwnerKind `json:"ownerKinds,omitempty"`
+	// PodSelector further restricts matching pods by label. An empty
+	// selector matches every pod owned by one of OwnerKinds.
+	PodSelector metav1.LabelSelector `json:"podSelector,omitempty"`
+	// NamespaceSelector restricts matching pods to namespaces whose labels
+	// match. A nil selector matches every namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ReadinessPredicate chooses which condition gating pods must satisfy.
+	// Defaults to PodReady.
+	ReadinessPredicate ReadinessPredicate `json:"readinessPredicate,omitempty"`
+	// CustomConditionType is required when ReadinessPredicate is
+	// CustomCondition.
+	CustomConditionType apiv1.PodConditionType `json:"customConditionType,omitempty"`
+	// ReadyDeadline, if set, is the maximum amount of time a node may carry
+	// this rule's taint before its offending non-ready gating pods are
+	// evicted to unstick a wedged bootstrap. Zero disables eviction.
+	ReadyDeadline metav1.Duration `json:"readyDeadline,omitempty"`
+
+	selector   labels.Selector
+	nsSelector labels.Selector
+}
+
+// LoadTaintRules reads and validates a list of TaintRules from a YAML or
+// JSON file on disk.
+func LoadTaintRules(path string) ([]TaintRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read taint rule config %s: %w", path, err)
+	}
+
+	var rules []TaintRule
+	if err := yaml.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse taint rule config %s: %w", path, err)
+	}
+
+	for i := range rules {
+		if err := rules[i].init(); err != nil {
+			return nil, fmt.Errorf("invalid taint rule %q: %w", rules[i].Name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// init fills in defaults and pre-compiles the rule's label selectors. It
+// must be called once before a rule is used for matching.
+func (r *TaintRule) init() error {
+	if r.Name == "" {
+		r.Name = r.TaintKey
+	}
+	if r.TaintKey == "" {
+		return fmt.Errorf("taintKey is required")
+	}
+	if len(r.OwnerKinds) == 0 {
+		r.OwnerKinds = []OwnerKind{OwnerKindDaemonSet}
+	}
+	if r.ReadinessPredicate == "" {
+		r.ReadinessPredicate = ReadinessPredicatePodReady
+	}
+	if r.ReadinessPredicate == ReadinessPredicateCustomCondition && r.CustomConditionType == "" {
+		return fmt.Errorf("customConditionType is required when readinessPredicate is CustomCondition")
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&r.PodSelector)
+	if err != nil {
+		return fmt.Errorf("invalid podSelector: %w", err)
+	}
+	r.selector = selector
+
+	if r.NamespaceSelector != nil {
+		nsSelector, err := metav1.LabelSelectorAsSelector(r.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+		r.nsSelector = nsSelector
+	}
+
+	return nil
+}
+
+// matchesTaint reports whether the rule applies to the given node taint.
+func (r *TaintRule) matchesTaint(taint apiv1.Taint) bool {
+	if taint.Key != r.TaintKey {
+		return false
+	}
+	if r.TaintEffect != "" && taint.Effect != r.TaintEffect {
+		return false
+	}
+	return true
+}
+
+// ownedByKind reports whether ownerKind is one of the rule's OwnerKinds.
+// ownerKind is resolved by the caller, since Deployment-managed pods are
+// controlled by their ReplicaSet rather than the Deployment directly and
+// resolving that requires an informer lookup TaintRule has no access to.
+func (r *TaintRule) ownedByKind(ownerKind string) bool {
+	if ownerKind == "" {
+		return false
+	}
+	for _, kind := range r.OwnerKinds {
+		if string(kind) == ownerKind {
+			return true
+		}
+	}
+	return false
+}
+
+// toleratesTaint reports whether the pod tolerates the rule's taint key.
+func (r *TaintRule) toleratesTaint(pod *apiv1.Pod) bool {
+	for _, toleration := range pod.Spec.Tolerations {
+		if toleration.Key == r.TaintKey {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNamespace reports whether the given namespace labels satisfy the
+// rule's NamespaceSelector.
+func (r *TaintRule) matchesNamespace(namespaceLabels map[string]string) bool {
+	if r.nsSelector == nil {
+		return true
+	}
+	return r.nsSelector.Matches(labels.Set(namespaceLabels))
+}
+
+// Matches reports whether pod is a gating pod for this rule, given its
+// resolved controller kind (see ownedByKind) and the labels of the
+// namespace it lives in.
+func (r *TaintRule) Matches(pod *apiv1.Pod, ownerKind string, namespaceLabels map[string]string) bool {
+	if !r.ownedByKind(ownerKind) {
+		return false
+	}
+	if !r.toleratesTaint(pod) {
+		return false
+	}
+	if !r.selector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+		return false
+	}
+	return r.matchesNamespace(namespaceLabels)
+}
+
+// IsPodReady reports whether pod satisfies the rule's ReadinessPredicate.
+func (r *TaintRule) IsPodReady(pod *apiv1.Pod) bool {
+	var conditionType apiv1.PodConditionType
+	switch r.ReadinessPredicate {
+	case ReadinessPredicateContainersReady:
+		conditionType = apiv1.ContainersReady
+	case ReadinessPredicateCustomCondition:
+		conditionType = r.CustomConditionType
+	default:
+		conditionType = apiv1.PodReady
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
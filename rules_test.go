@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func podWithLabels(labels map[string]string, tolerationKey string) *apiv1.Pod {
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	if tolerationKey != "" {
+		pod.Spec.Tolerations = []apiv1.Toleration{{Key: tolerationKey}}
+	}
+	return pod
+}
+
+func TestTaintRuleInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    TaintRule
+		wantErr bool
+	}{
+		{"requires taintKey", TaintRule{Name: "test"}, true},
+		{"defaults name to taintKey", TaintRule{TaintKey: "example.com/x"}, false},
+		{"custom condition requires customConditionType", TaintRule{TaintKey: "example.com/x", ReadinessPredicate: ReadinessPredicateCustomCondition}, true},
+		{"custom condition with customConditionType is valid", TaintRule{TaintKey: "example.com/x", ReadinessPredicate: ReadinessPredicateCustomCondition, CustomConditionType: "example.com/warmed-up"}, false},
+		{"invalid podSelector operator", TaintRule{TaintKey: "example.com/x", PodSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Bogus"}}}}, true},
+		{"invalid namespaceSelector operator", TaintRule{TaintKey: "example.com/x", NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Bogus"}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			err := rule.init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTaintRuleInitDefaults(t *testing.T) {
+	rule := TaintRule{TaintKey: "example.com/x"}
+	if err := rule.init(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Name != rule.TaintKey {
+		t.Errorf("expected Name to default to TaintKey, got %q", rule.Name)
+	}
+	if len(rule.OwnerKinds) != 1 || rule.OwnerKinds[0] != OwnerKindDaemonSet {
+		t.Errorf("expected OwnerKinds to default to [DaemonSet], got %v", rule.OwnerKinds)
+	}
+	if rule.ReadinessPredicate != ReadinessPredicatePodReady {
+		t.Errorf("expected ReadinessPredicate to default to PodReady, got %q", rule.ReadinessPredicate)
+	}
+}
+
+func TestTaintRuleMatches(t *testing.T) {
+	rule := TaintRule{
+		Name:        "test",
+		TaintKey:    "example.com/not-ready",
+		PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "gate"}},
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"env": "prod"},
+		},
+	}
+	if err := rule.init(); err != nil {
+		t.Fatalf("failed to init rule: %v", err)
+	}
+
+	prodLabels := map[string]string{"env": "prod"}
+	stagingLabels := map[string]string{"env": "staging"}
+	gatingPod := podWithLabels(map[string]string{"app": "gate"}, rule.TaintKey)
+
+	tests := []struct {
+		name            string
+		pod             *apiv1.Pod
+		ownerKind       string
+		namespaceLabels map[string]string
+		want            bool
+	}{
+		{"matches everything", gatingPod, string(OwnerKindDaemonSet), prodLabels, true},
+		{"wrong owner kind", gatingPod, string(OwnerKindStatefulSet), prodLabels, false},
+		{"no controller owner", gatingPod, "", prodLabels, false},
+		{"does not tolerate taint", podWithLabels(map[string]string{"app": "gate"}, ""), string(OwnerKindDaemonSet), prodLabels, false},
+		{"label selector mismatch", podWithLabels(map[string]string{"app": "other"}, rule.TaintKey), string(OwnerKindDaemonSet), prodLabels, false},
+		{"namespace selector mismatch", gatingPod, string(OwnerKindDaemonSet), stagingLabels, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rule.Matches(tt.pod, tt.ownerKind, tt.namespaceLabels); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaintRuleMatchesNilNamespaceSelector(t *testing.T) {
+	rule := TaintRule{Name: "test", TaintKey: "example.com/not-ready"}
+	if err := rule.init(); err != nil {
+		t.Fatalf("failed to init rule: %v", err)
+	}
+
+	pod := podWithLabels(nil, rule.TaintKey)
+	if !rule.Matches(pod, string(OwnerKindDaemonSet), map[string]string{"env": "anything"}) {
+		t.Errorf("expected pod to match when NamespaceSelector is nil, regardless of namespace labels")
+	}
+}
+
+func TestTaintRuleIsPodReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		predicate  ReadinessPredicate
+		customType apiv1.PodConditionType
+		conditions []apiv1.PodCondition
+		want       bool
+	}{
+		{"pod ready true", ReadinessPredicatePodReady, "", []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionTrue}}, true},
+		{"pod ready false", ReadinessPredicatePodReady, "", []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionFalse}}, false},
+		{"pod ready condition missing", ReadinessPredicatePodReady, "", nil, false},
+		{"containers ready true", ReadinessPredicateContainersReady, "", []apiv1.PodCondition{{Type: apiv1.ContainersReady, Status: apiv1.ConditionTrue}}, true},
+		{"containers ready ignores pod ready", ReadinessPredicateContainersReady, "", []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionTrue}}, false},
+		{"custom condition true", ReadinessPredicateCustomCondition, "example.com/warmed-up", []apiv1.PodCondition{{Type: "example.com/warmed-up", Status: apiv1.ConditionTrue}}, true},
+		{"custom condition false", ReadinessPredicateCustomCondition, "example.com/warmed-up", []apiv1.PodCondition{{Type: "example.com/warmed-up", Status: apiv1.ConditionFalse}}, false},
+		{"custom condition missing", ReadinessPredicateCustomCondition, "example.com/warmed-up", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := TaintRule{
+				Name:                "test",
+				TaintKey:            "example.com/not-ready",
+				ReadinessPredicate:  tt.predicate,
+				CustomConditionType: tt.customType,
+			}
+			if err := rule.init(); err != nil {
+				t.Fatalf("failed to init rule: %v", err)
+			}
+
+			pod := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: tt.conditions}}
+			if got := rule.IsPodReady(pod); got != tt.want {
+				t.Errorf("IsPodReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}